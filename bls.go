@@ -0,0 +1,37 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	blschia "github.com/chia-network/bls-signatures/go-bindings"
+)
+
+// verifyBLSVote checks a BLS signature (as used by DIP-0024 HPMN/EvoNode
+// operator keys) over the canonicalized vote message, against the
+// pubKeyOperator recorded for that masternode in mnList.
+func verifyBLSVote(pubKeyOperatorHex string, message []byte, signatureHex string) error {
+	pubKeyBytes, err := hex.DecodeString(pubKeyOperatorHex)
+	if err != nil {
+		return fmt.Errorf("malformed pubKeyOperator: %w", err)
+	}
+	sigBytes, err := hex.DecodeString(signatureHex)
+	if err != nil {
+		return fmt.Errorf("malformed BLS signature: %w", err)
+	}
+
+	pubKey, err := blschia.G1ElementFromBytes(pubKeyBytes)
+	if err != nil {
+		return fmt.Errorf("invalid pubKeyOperator: %w", err)
+	}
+	sig, err := blschia.G2ElementFromBytes(sigBytes)
+	if err != nil {
+		return fmt.Errorf("invalid BLS signature: %w", err)
+	}
+
+	scheme := blschia.NewBasicSchemeMPL()
+	if !scheme.Verify(pubKey, message, sig) {
+		return fmt.Errorf("BLS signature does not verify against pubKeyOperator")
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+// Command signjwt mints an ES256 or RS256 JWT for the vote-collector's
+// JWKS-based auth, so an operator can grant time-limited audit access (to
+// /api/validVotes, /api/allVotes, etc.) without redeploying the server or
+// sharing a secret. Mirrors the telebit signjwt tool.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dashhive/vote-collector/jwkkey"
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+func main() {
+	keyFile := flag.String("key", "", "path to the EC or RSA private key (PEM or JWK) to sign with")
+	sub := flag.String("sub", "", "subject (e.g. the auditor's name or email)")
+	aud := flag.String("aud", "vote-collector", "audience")
+	iss := flag.String("iss", "", "issuer (defaults to sub if unset)")
+	kid := flag.String("kid", "", "key id to stamp into the token header (defaults to a hash of the public key)")
+	ttl := flag.Duration("ttl", 24*time.Hour, "how long the token should be valid for")
+	flag.Parse()
+
+	if *keyFile == "" || *sub == "" {
+		fmt.Fprintln(os.Stderr, "Usage: signjwt -key <private-key.pem|.json> -sub <name> [-aud vote-collector] [-iss ...] [-kid ...] [-ttl 24h]")
+		os.Exit(1)
+	}
+
+	key, err := jwkkey.LoadPrivateKeyFile(*keyFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	method, err := jwkkey.SigningMethodFor(key)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	issuer := *iss
+	if issuer == "" {
+		issuer = *sub
+	}
+
+	keyID := *kid
+	if keyID == "" {
+		seed, err := jwkkey.KeyIDSeed(key)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		keyID = jwkkey.DeriveKeyID(seed)
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"sub": *sub,
+		"aud": *aud,
+		"iss": issuer,
+		"iat": now.Unix(),
+		"exp": now.Add(*ttl).Unix(),
+	}
+
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = keyID
+
+	signed, err := token.SignedString(key)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: signing token: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println(signed)
+}
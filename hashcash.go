@@ -0,0 +1,244 @@
+package main
+
+import (
+	"container/list"
+	"crypto/hmac"
+	cryptorand "crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// hashcashBits is the required number of leading zero bits in a
+// challenge's hash, configurable via HASHCASH_BITS. Defaults to ~20,
+// which is cheap for a single honest vote but expensive to flood.
+var hashcashBits = envIntOr("HASHCASH_BITS", 20)
+
+// hashcashTTL bounds how long an issued challenge stays valid.
+const hashcashTTL = 5 * time.Minute
+
+// hashcashSecret HMACs issued challenges so they can be verified
+// statelessly, without the server having to remember what it handed out.
+// An empty key would let anyone forge challenges, so mustLoadHashcashSecret
+// fails closed rather than letting the server start with one.
+var hashcashSecret []byte
+
+// mustLoadHashcashSecret exits the process if HASHCASH_SECRET is unset.
+// It's deliberately not an init() - call it from main() before serving,
+// so `go test` can exercise the rest of this package (e.g.
+// TestCountLeadingZeroBits) without carrying production bootstrap state.
+func mustLoadHashcashSecret() {
+	secret := os.Getenv("HASHCASH_SECRET")
+	if secret == "" {
+		log.Fatal("HASHCASH_SECRET must be set so hashcash challenges can't be forged")
+	}
+	hashcashSecret = []byte(secret)
+}
+
+func envIntOr(key string, fallback int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return fallback
+}
+
+// hashcashChallenge is handed to a client so it can mine a valid stamp
+// for a specific voting address. Nonce is `<rand>.<hmac>`, which is what
+// lets verifyHashcash check the stamp statelessly, without the server
+// having to remember which challenges it issued.
+type hashcashChallenge struct {
+	Resource  string `json:"resource"`
+	Nonce     string `json:"nonce"`
+	Bits      int    `json:"bits"`
+	ExpiresAt int64  `json:"expiresAt"`
+}
+
+// signChallenge HMACs the challenge fields so it can be verified later
+// without server-side state.
+func signChallenge(resource, nonce string, bits int, expiresAt int64) string {
+	mac := hmac.New(sha256.New, hashcashSecret)
+	fmt.Fprintf(mac, "%s|%s|%d|%d", resource, nonce, bits, expiresAt)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// handleNewHashcash issues a short-lived, signed PoW challenge scoped to
+// the requested voting address.
+func (s *server) handleNewHashcash() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resource := r.URL.Query().Get("resource")
+		if !isValidAddress(resource, os.Getenv("DASH_NETWORK")) {
+			writeErrorMessage("INVALID_NETWORK", http.StatusBadRequest, w, r)
+			return
+		}
+
+		rand := randomHex(16)
+		expiresAt := time.Now().Add(hashcashTTL).Unix()
+		sig := signChallenge(resource, rand, hashcashBits, expiresAt)
+
+		challenge := hashcashChallenge{
+			Resource:  resource,
+			Nonce:     rand + "." + sig,
+			Bits:      hashcashBits,
+			ExpiresAt: expiresAt,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(challenge)
+	}
+}
+
+// hashcashNonceCache is a small in-memory LRU, keyed by expiry, that
+// prevents a stamp from being replayed within its own validity window.
+type hashcashNonceCache struct {
+	mu       sync.Mutex
+	seen     map[string]*list.Element
+	order    *list.List
+	capacity int
+}
+
+type hashcashNonceEntry struct {
+	nonce     string
+	expiresAt time.Time
+}
+
+var usedHashcashNonces = newHashcashNonceCache(10000)
+
+func newHashcashNonceCache(capacity int) *hashcashNonceCache {
+	return &hashcashNonceCache{
+		seen:     map[string]*list.Element{},
+		order:    list.New(),
+		capacity: capacity,
+	}
+}
+
+// claim records a nonce as spent, evicting expired/oldest entries first.
+// It returns false if the nonce was already used.
+func (c *hashcashNonceCache) claim(nonce string, expiresAt time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.evictExpired()
+
+	if _, ok := c.seen[nonce]; ok {
+		return false
+	}
+
+	if c.order.Len() >= c.capacity {
+		oldest := c.order.Front()
+		if oldest != nil {
+			c.order.Remove(oldest)
+			delete(c.seen, oldest.Value.(*hashcashNonceEntry).nonce)
+		}
+	}
+
+	el := c.order.PushBack(&hashcashNonceEntry{nonce: nonce, expiresAt: expiresAt})
+	c.seen[nonce] = el
+	return true
+}
+
+func (c *hashcashNonceCache) evictExpired() {
+	now := time.Now()
+	for el := c.order.Front(); el != nil; {
+		next := el.Next()
+		entry := el.Value.(*hashcashNonceEntry)
+		if entry.expiresAt.After(now) {
+			break
+		}
+		c.order.Remove(el)
+		delete(c.seen, entry.nonce)
+		el = next
+	}
+}
+
+// verifyHashcash parses and checks a `Hashcash:` header value in the
+// `1:<bits>:<expiresAt>:<resource>::<nonce>.<sig>:<counter>` form (the
+// `<rand>` slot of the classic hashcash format carries our nonce and its
+// HMAC together, which is what lets us verify the challenge was really
+// one we issued without keeping any server-side state). The stamp must
+// be unexpired, scoped to `resource`, unused, and hash to at least
+// `bits` leading zero bits.
+func verifyHashcash(header string, resource string) error {
+	fields := strings.Split(header, ":")
+	if len(fields) != 7 || fields[0] != "1" {
+		return fmt.Errorf("malformed hashcash stamp")
+	}
+
+	bits, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return fmt.Errorf("malformed hashcash bits")
+	}
+	if bits < hashcashBits {
+		return fmt.Errorf("hashcash stamp does not meet required difficulty")
+	}
+
+	expiresAt, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return fmt.Errorf("malformed hashcash timestamp")
+	}
+	expiry := time.Unix(expiresAt, 0)
+	if time.Now().After(expiry) {
+		return fmt.Errorf("hashcash challenge has expired")
+	}
+
+	stampResource := fields[3]
+	if stampResource != resource {
+		return fmt.Errorf("hashcash stamp is not scoped to this address")
+	}
+
+	nonce, sig, ok := strings.Cut(fields[5], ".")
+	if !ok {
+		return fmt.Errorf("malformed hashcash nonce")
+	}
+	if sig != signChallenge(resource, nonce, bits, expiresAt) {
+		return fmt.Errorf("hashcash challenge was not issued by this server")
+	}
+
+	sum := sha256.Sum256([]byte(header))
+	if countLeadingZeroBits(sum[:]) < bits {
+		return fmt.Errorf("hashcash stamp does not satisfy its claimed difficulty")
+	}
+
+	if !usedHashcashNonces.claim(nonce, expiry) {
+		return fmt.Errorf("hashcash stamp has already been used")
+	}
+
+	return nil
+}
+
+// randomHex returns n random bytes, hex-encoded.
+func randomHex(n int) string {
+	buf := make([]byte, n)
+	_, _ = cryptorand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// countLeadingZeroBits counts the number of leading zero bits in b.
+func countLeadingZeroBits(b []byte) int {
+	count := 0
+	for _, by := range b {
+		if by == 0 {
+			count += 8
+			continue
+		}
+		for mask := byte(0x80); mask > 0; mask >>= 1 {
+			if by&mask != 0 {
+				return count
+			}
+			count++
+		}
+	}
+	return count
+}
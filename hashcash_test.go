@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCountLeadingZeroBits(t *testing.T) {
+	cases := []struct {
+		name string
+		b    []byte
+		want int
+	}{
+		{"all zero", []byte{0x00, 0x00}, 16},
+		{"no leading zero", []byte{0xff}, 0},
+		{"one leading zero bit", []byte{0x7f}, 1},
+		{"byte boundary", []byte{0x00, 0x0f}, 12},
+		{"empty", nil, 0},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := countLeadingZeroBits(c.b); got != c.want {
+				t.Errorf("countLeadingZeroBits(%v) = %d, want %d", c.b, got, c.want)
+			}
+		})
+	}
+}
+
+func TestVerifyHashcash(t *testing.T) {
+	hashcashSecret = []byte("test-secret")
+	hashcashBits = 0 // don't actually mine in a test
+
+	resource := "Xr1fakeaddress000000000000000000"
+	nonce := "abc123"
+	bits := 0
+	expiresAt := time.Now().Add(time.Minute).Unix()
+	sig := signChallenge(resource, nonce, bits, expiresAt)
+
+	header := fmt.Sprintf("1:%d:%d:%s::%s.%s:0", bits, expiresAt, resource, nonce, sig)
+	if err := verifyHashcash(header, resource); err != nil {
+		t.Fatalf("verifyHashcash() = %v, want nil", err)
+	}
+
+	// replay of the same stamp must be rejected
+	if err := verifyHashcash(header, resource); err == nil {
+		t.Fatal("verifyHashcash() on a replayed stamp = nil, want an error")
+	}
+}
+
+func TestVerifyHashcashRejectsWrongResource(t *testing.T) {
+	hashcashSecret = []byte("test-secret")
+	hashcashBits = 0
+
+	nonce := "def456"
+	bits := 0
+	expiresAt := time.Now().Add(time.Minute).Unix()
+	sig := signChallenge("resource-a", nonce, bits, expiresAt)
+
+	header := fmt.Sprintf("1:%d:%d:%s::%s.%s:0", bits, expiresAt, "resource-a", nonce, sig)
+	if err := verifyHashcash(header, "resource-b"); err == nil {
+		t.Fatal("verifyHashcash() with mismatched resource = nil, want an error")
+	}
+}
+
+func TestVerifyHashcashRejectsExpired(t *testing.T) {
+	hashcashSecret = []byte("test-secret")
+	hashcashBits = 0
+
+	resource := "r"
+	nonce := "ghi789"
+	bits := 0
+	expiresAt := time.Now().Add(-time.Minute).Unix()
+	sig := signChallenge(resource, nonce, bits, expiresAt)
+
+	header := fmt.Sprintf("1:%d:%d:%s::%s.%s:0", bits, expiresAt, resource, nonce, sig)
+	if err := verifyHashcash(header, resource); err == nil {
+		t.Fatal("verifyHashcash() on an expired stamp = nil, want an error")
+	}
+}
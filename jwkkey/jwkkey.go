@@ -0,0 +1,257 @@
+// Package jwkkey loads and publishes EC P-256/RSA keys in PEM or JWK
+// form, shared by the server (jwks.go, which publishes its own public
+// key and verifies against trusted remote JWKS) and cmd/signjwt (which
+// loads a private key to mint tokens). Keeping this in one place avoids
+// the two copies drifting apart.
+package jwkkey
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// JWK is the subset of RFC 7518 needed to publish or load an EC P-256 or
+// RSA key, public or private.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+
+	// EC (kty "EC")
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+
+	// RSA (kty "RSA")
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// D is the private exponent/scalar, present only when loading a
+	// private key, never when publishing a public JWKS.
+	D string `json:"d,omitempty"`
+	P string `json:"p,omitempty"`
+	Q string `json:"q,omitempty"`
+}
+
+// JWKSet is a standard JWKS document, as served at /.well-known/jwks.json.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// ToPublicKey converts an EC P-256 or RSA JWK to a usable public key.
+func (k JWK) ToPublicKey() (interface{}, error) {
+	switch k.Kty {
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q (only P-256 is supported)", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("bad x coordinate: %w", err)
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("bad y coordinate: %w", err)
+		}
+		return &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("bad modulus: %w", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("bad exponent: %w", err)
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// ToPrivateKey converts a private EC P-256 or RSA JWK (one with a D
+// component) to a usable private key.
+func (k JWK) ToPrivateKey() (interface{}, error) {
+	if k.D == "" {
+		return nil, fmt.Errorf("jwk has no private component (d)")
+	}
+
+	switch k.Kty {
+	case "EC":
+		pub, err := k.ToPublicKey()
+		if err != nil {
+			return nil, err
+		}
+		d, err := base64.RawURLEncoding.DecodeString(k.D)
+		if err != nil {
+			return nil, fmt.Errorf("bad d: %w", err)
+		}
+		return &ecdsa.PrivateKey{
+			PublicKey: *pub.(*ecdsa.PublicKey),
+			D:         new(big.Int).SetBytes(d),
+		}, nil
+
+	case "RSA":
+		pub, err := k.ToPublicKey()
+		if err != nil {
+			return nil, err
+		}
+		d, err := base64.RawURLEncoding.DecodeString(k.D)
+		if err != nil {
+			return nil, fmt.Errorf("bad d: %w", err)
+		}
+		p, err := base64.RawURLEncoding.DecodeString(k.P)
+		if err != nil {
+			return nil, fmt.Errorf("bad p: %w", err)
+		}
+		q, err := base64.RawURLEncoding.DecodeString(k.Q)
+		if err != nil {
+			return nil, fmt.Errorf("bad q: %w", err)
+		}
+		priv := &rsa.PrivateKey{
+			PublicKey: *pub.(*rsa.PublicKey),
+			D:         new(big.Int).SetBytes(d),
+			Primes:    []*big.Int{new(big.Int).SetBytes(p), new(big.Int).SetBytes(q)},
+		}
+		priv.Precompute()
+		return priv, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// PublicKeyToJWK converts an EC or RSA public key to its (public-only)
+// JWK form.
+func PublicKeyToJWK(pub interface{}, kid string) (JWK, error) {
+	switch key := pub.(type) {
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		return JWK{
+			Kty: "EC",
+			Crv: "P-256",
+			X:   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, size))),
+			Y:   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, size))),
+			Kid: kid,
+			Use: "sig",
+			Alg: "ES256",
+		}, nil
+
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			Kid: kid,
+			Use: "sig",
+			Alg: "RS256",
+		}, nil
+
+	default:
+		return JWK{}, fmt.Errorf("unsupported public key type %T", pub)
+	}
+}
+
+// LoadPrivateKeyFile reads an EC (SEC1), RSA (PKCS1), PKCS8 (either), or
+// private-JWK-JSON private key from path.
+func LoadPrivateKeyFile(path string) (interface{}, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading %q: %w", path, err)
+	}
+
+	var key interface{}
+	if bytes.HasPrefix(bytes.TrimSpace(raw), []byte("{")) {
+		key, err = privateKeyFromJWK(raw)
+	} else {
+		key, err = privateKeyFromPEM(raw)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loading %q: %w", path, err)
+	}
+	return key, nil
+}
+
+// privateKeyFromPEM parses an EC (SEC1), RSA (PKCS1), or PKCS8 (either)
+// private key PEM block.
+func privateKeyFromPEM(raw []byte) (interface{}, error) {
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+}
+
+// privateKeyFromJWK parses a private EC or RSA key from JWK JSON.
+func privateKeyFromJWK(raw []byte) (interface{}, error) {
+	var k JWK
+	if err := json.Unmarshal(raw, &k); err != nil {
+		return nil, fmt.Errorf("decoding jwk: %w", err)
+	}
+	return k.ToPrivateKey()
+}
+
+// SigningMethodFor returns the jwt.SigningMethod implied by a private or
+// public key's type: ES256 for an EC key, RS256 for an RSA one.
+func SigningMethodFor(key interface{}) (jwt.SigningMethod, error) {
+	switch key.(type) {
+	case *ecdsa.PrivateKey, *ecdsa.PublicKey:
+		return jwt.SigningMethodES256, nil
+	case *rsa.PrivateKey, *rsa.PublicKey:
+		return jwt.SigningMethodRS256, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+}
+
+// KeyIDSeed returns the key material DeriveKeyID should hash into a
+// default kid: the public X coordinate for an EC key, the modulus for an
+// RSA one.
+func KeyIDSeed(key interface{}) ([]byte, error) {
+	switch k := key.(type) {
+	case *ecdsa.PrivateKey:
+		return k.X.Bytes(), nil
+	case *rsa.PrivateKey:
+		return k.N.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+}
+
+// DeriveKeyID derives a default kid from key material, overridable by
+// callers that have their own naming convention (e.g. JWT_KEY_ID).
+func DeriveKeyID(material []byte) string {
+	digest := sha256.Sum256(material)
+	sum := base64.RawURLEncoding.EncodeToString(digest[:])
+	return "vote-collector-" + sum[:8]
+}
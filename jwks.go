@@ -0,0 +1,232 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dashhive/vote-collector/jwkkey"
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// serverSigningKey is the private key this instance uses to sign JWTs
+// (and, for auditors, vote receipts): either an *ecdsa.PrivateKey or an
+// *rsa.PrivateKey, matching serverSigningMethod. It replaces the old
+// shared JWTSecretKey.
+var serverSigningKey interface{}
+
+// serverSigningMethod is ES256 for an EC key, RS256 for an RSA one.
+var serverSigningMethod jwt.SigningMethod
+
+// serverKeyID is the `kid` this instance advertises in its own JWKS and
+// stamps into everything it signs.
+var serverKeyID string
+
+// jwksKeystore resolves a token's `kid` to the public key that should
+// verify it, caching remote JWKS (from other trusted issuers, e.g. an
+// auditor's own signjwt key) so that `isAuthorizedOrTimely` never has to
+// block on a network round-trip for our own key.
+type jwksKeystore struct {
+	mu         sync.RWMutex
+	keys       map[string]interface{} // *ecdsa.PublicKey or *rsa.PublicKey
+	remoteURLs []string
+	fetchedAt  map[string]time.Time
+}
+
+var keystore = &jwksKeystore{
+	keys:      map[string]interface{}{},
+	fetchedAt: map[string]time.Time{},
+}
+
+// keyFunc looks up the verification key for a parsed-but-unverified token
+// by its `kid` header, refreshing any configured remote JWKS at most once
+// per minute.
+func (ks *jwksKeystore) keyFunc(token *jwt.Token) (interface{}, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodECDSA, *jwt.SigningMethodRSA:
+		// ok
+	default:
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	kid, ok := token.Header["kid"].(string)
+	if !ok || kid == "" {
+		return nil, fmt.Errorf("token is missing a kid")
+	}
+
+	ks.mu.RLock()
+	key, ok := ks.keys[kid]
+	ks.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+
+	if err := ks.refresh(); err != nil {
+		return nil, err
+	}
+
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	if key, ok := ks.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown kid: %s", kid)
+}
+
+// refresh re-fetches every trusted remote JWKS. Our own key is always
+// present and never expires out of the cache.
+func (ks *jwksKeystore) refresh() error {
+	for _, u := range ks.remoteURLs {
+		ks.mu.Lock()
+		due := time.Since(ks.fetchedAt[u]) >= time.Minute
+		if due {
+			ks.fetchedAt[u] = time.Now()
+		}
+		ks.mu.Unlock()
+		if !due {
+			continue
+		}
+
+		resp, err := http.Get(u)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: fetching trusted jwks %q: %v\n", u, err)
+			continue
+		}
+
+		var set jwkkey.JWKSet
+		err = json.NewDecoder(resp.Body).Decode(&set)
+		resp.Body.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: decoding trusted jwks %q: %v\n", u, err)
+			continue
+		}
+
+		ks.mu.Lock()
+		for _, k := range set.Keys {
+			pub, err := k.ToPublicKey()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: parsing jwk %q from %q: %v\n", k.Kid, u, err)
+				continue
+			}
+			ks.keys[k.Kid] = pub
+		}
+		ks.mu.Unlock()
+	}
+	return nil
+}
+
+// trust registers a local public key (typically our own) under its kid.
+func (ks *jwksKeystore) trust(kid string, pub interface{}) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.keys[kid] = pub
+}
+
+// handleJWKS serves this server's own public key(s) so that operators can
+// add it to their own trusted-issuer lists.
+func (s *server) handleJWKS() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jwk, err := serverPublicJWK()
+		if err != nil {
+			writeError(http.StatusInternalServerError, w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(jwkkey.JWKSet{Keys: []jwkkey.JWK{jwk}})
+	}
+}
+
+// serverPublicJWK returns this instance's own public key in JWK form.
+func serverPublicJWK() (jwkkey.JWK, error) {
+	switch key := serverSigningKey.(type) {
+	case *ecdsa.PrivateKey:
+		return jwkkey.PublicKeyToJWK(&key.PublicKey, serverKeyID)
+	case *rsa.PrivateKey:
+		return jwkkey.PublicKeyToJWK(&key.PublicKey, serverKeyID)
+	default:
+		return jwkkey.JWK{}, fmt.Errorf("no signing key loaded")
+	}
+}
+
+// loadServerSigningKey reads the private key named by
+// JWT_PRIVATE_KEY_FILE (PEM - SEC1/PKCS1/PKCS8 - or a private JWK JSON
+// document) and derives the kid to stamp into our own JWKS, overridable
+// via JWT_KEY_ID. Either an EC P-256 or an RSA key is accepted; the
+// signing algorithm (ES256/RS256) follows from the key type.
+func loadServerSigningKey() error {
+	path := os.Getenv("JWT_PRIVATE_KEY_FILE")
+	if path == "" {
+		return fmt.Errorf("JWT_PRIVATE_KEY_FILE must point at an EC or RSA private key (PEM or JWK)")
+	}
+
+	key, err := jwkkey.LoadPrivateKeyFile(path)
+	if err != nil {
+		return err
+	}
+
+	method, err := jwkkey.SigningMethodFor(key)
+	if err != nil {
+		return err
+	}
+	seed, err := jwkkey.KeyIDSeed(key)
+	if err != nil {
+		return err
+	}
+
+	serverSigningKey = key
+	serverSigningMethod = method
+	serverKeyID = jwkkey.DeriveKeyID(seed)
+	if kid := os.Getenv("JWT_KEY_ID"); kid != "" {
+		serverKeyID = kid
+	}
+
+	jwk, err := serverPublicJWK()
+	if err != nil {
+		return err
+	}
+	pub, err := jwk.ToPublicKey()
+	if err != nil {
+		return err
+	}
+	keystore.trust(serverKeyID, pub)
+
+	if urls := os.Getenv("JWT_TRUSTED_JWKS_URLS"); urls != "" {
+		keystore.remoteURLs = splitAndTrim(urls, ",")
+	}
+
+	return nil
+}
+
+// mustLoadServerSigningKey exits the process if the signing key can't be
+// loaded, since every request handler assumes serverSigningKey is set.
+// It's deliberately not an init() - call it from main() before serving,
+// so `go test` can exercise the rest of this package without carrying
+// production bootstrap state (a real JWT_PRIVATE_KEY_FILE).
+func mustLoadServerSigningKey() {
+	if err := loadServerSigningKey(); err != nil {
+		log.Fatalf("Error: %v\n", err)
+	}
+}
+
+// splitAndTrim splits s on sep and trims whitespace from each piece,
+// dropping any that are empty.
+func splitAndTrim(s string, sep string) []string {
+	var out []string
+	for _, piece := range strings.Split(s, sep) {
+		piece = strings.TrimSpace(piece)
+		if piece != "" {
+			out = append(out, piece)
+		}
+	}
+	return out
+}
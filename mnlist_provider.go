@@ -0,0 +1,332 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// MNListProvider produces the current masternode list. Implementations
+// may hit an external HTTP service, a dashd RPC node, Dash Platform, or a
+// local file - getMNList used to hardcode the first of these and silently
+// return nil on any error, which then poisoned updateLists.
+type MNListProvider interface {
+	// Name identifies the provider for the /api/mnlist/source diagnostic.
+	Name() string
+	// GetMNList returns the current masternode list, or an error. It must
+	// respect ctx cancellation/deadline.
+	GetMNList(ctx context.Context) (map[string]MNInfo, error)
+	// Status reports diagnostic info for /api/mnlist/source. Providers
+	// that don't track fetch history (i.e. everything but
+	// CachingProvider) just report their Name with a zero-value the rest
+	// of the way.
+	Status() mnlistSourceStatus
+}
+
+// staticStatus is the Status() a provider reports when it doesn't itself
+// track fetch history (that's CachingProvider's job).
+func staticStatus(name string) mnlistSourceStatus {
+	return mnlistSourceStatus{Source: name}
+}
+
+// HTTPMNListProvider is the original behavior: a JSON GET against a
+// configured URL.
+type HTTPMNListProvider struct {
+	URL string
+}
+
+func (p *HTTPMNListProvider) Name() string { return "http:" + p.URL }
+
+func (p *HTTPMNListProvider) Status() mnlistSourceStatus { return staticStatus(p.Name()) }
+
+func (p *HTTPMNListProvider) GetMNList(ctx context.Context) (map[string]MNInfo, error) {
+	c := &http.Client{
+		Transport: &http.Transport{
+			Dial: (&net.Dialer{
+				Timeout:   30 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).Dial,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: 10 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+		},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", p.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("building mnlist request: %w", err)
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching mnlist: %w", err)
+	}
+	defer resp.Body.Close()
+
+	mninfo := map[string]MNInfo{}
+	if err := json.NewDecoder(resp.Body).Decode(&mninfo); err != nil {
+		return nil, fmt.Errorf("decoding mnlist: %w", err)
+	}
+	return mninfo, nil
+}
+
+// DashdRPCMNListProvider fetches `protx list valid 1` from a dashd node
+// over JSON-RPC, using basic-auth credentials from the environment.
+type DashdRPCMNListProvider struct {
+	URL      string
+	User     string
+	Password string
+}
+
+type dashdRPCRequest struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      string        `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type dashdRPCResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (p *DashdRPCMNListProvider) Name() string { return "dashd-rpc:" + p.URL }
+
+func (p *DashdRPCMNListProvider) Status() mnlistSourceStatus { return staticStatus(p.Name()) }
+
+func (p *DashdRPCMNListProvider) GetMNList(ctx context.Context) (map[string]MNInfo, error) {
+	body, err := json.Marshal(dashdRPCRequest{
+		JSONRPC: "1.0",
+		ID:      "vote-collector",
+		Method:  "protx",
+		Params:  []interface{}{"list", "valid", true},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("building protx request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("building protx request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth(p.User, p.Password)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("calling dashd protx: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var rpcResp dashdRPCResponse
+	if err := json.NewDecoder(resp.Body).Decode(&rpcResp); err != nil {
+		return nil, fmt.Errorf("decoding protx response: %w", err)
+	}
+	if rpcResp.Error != nil {
+		return nil, fmt.Errorf("protx error %d: %s", rpcResp.Error.Code, rpcResp.Error.Message)
+	}
+
+	// protx returns a list of ProRegTx details rather than our map
+	// shape, so translate it into a map keyed by ProTxHash, carrying each
+	// entry's voting address and operator key.
+	var entries []protxEntry
+	if err := json.Unmarshal(rpcResp.Result, &entries); err != nil {
+		return nil, fmt.Errorf("decoding protx entries: %w", err)
+	}
+
+	mninfo := map[string]MNInfo{}
+	for _, e := range entries {
+		mninfo[e.ProTxHash] = MNInfo{
+			VotingAddress:  e.State.VotingAddress,
+			PubKeyOperator: e.State.PubKeyOperator,
+		}
+	}
+	return mninfo, nil
+}
+
+// protxEntry is the relevant subset of `protx list valid 1` output.
+type protxEntry struct {
+	ProTxHash string `json:"proTxHash"`
+	State     struct {
+		VotingAddress  string `json:"votingAddress"`
+		PubKeyOperator string `json:"pubKeyOperator"`
+	} `json:"state"`
+}
+
+// PlatformMNListProvider is the Dash Platform (gRPC) backend selected by
+// MNLIST_PROVIDER=platform. Its GetMNList does not contact Platform: it
+// always returns an error and never produces a list. Platform's node list
+// is served from a different service than dashd's JSON-RPC, so this is
+// kept as its own provider rather than folded into DashdRPCMNListProvider.
+type PlatformMNListProvider struct {
+	Endpoint string
+}
+
+func (p *PlatformMNListProvider) Name() string { return "platform:" + p.Endpoint }
+
+func (p *PlatformMNListProvider) Status() mnlistSourceStatus { return staticStatus(p.Name()) }
+
+func (p *PlatformMNListProvider) GetMNList(ctx context.Context) (map[string]MNInfo, error) {
+	// TODO: wire up the Platform gRPC client once it's vendored; for now
+	// this provider exists so deployments can select it in config ahead
+	// of that work landing.
+	return nil, fmt.Errorf("platform mnlist provider is not yet implemented")
+}
+
+// StaticFileMNListProvider reads a local JSON file, matching the shape
+// the HTTP provider consumes. Useful for offline/testnet development.
+type StaticFileMNListProvider struct {
+	Path string
+}
+
+func (p *StaticFileMNListProvider) Name() string { return "file:" + p.Path }
+
+func (p *StaticFileMNListProvider) Status() mnlistSourceStatus { return staticStatus(p.Name()) }
+
+func (p *StaticFileMNListProvider) GetMNList(ctx context.Context) (map[string]MNInfo, error) {
+	raw, err := ioutil.ReadFile(p.Path)
+	if err != nil {
+		return nil, fmt.Errorf("reading %q: %w", p.Path, err)
+	}
+
+	mninfo := map[string]MNInfo{}
+	if err := json.Unmarshal(raw, &mninfo); err != nil {
+		return nil, fmt.Errorf("decoding %q: %w", p.Path, err)
+	}
+	return mninfo, nil
+}
+
+// CachingProvider wraps another MNListProvider with a context-based
+// timeout, exponential backoff on failure, and in-memory caching of the
+// last-good result so that a transient upstream failure doesn't poison
+// updateLists the way the old getMNList did.
+type CachingProvider struct {
+	Inner   MNListProvider
+	Timeout time.Duration
+
+	mu        sync.RWMutex
+	cached    map[string]MNInfo
+	lastErr   error
+	lastFetch time.Time
+	nextRetry time.Time
+	backoff   time.Duration
+}
+
+func NewCachingProvider(inner MNListProvider, timeout time.Duration) *CachingProvider {
+	return &CachingProvider{Inner: inner, Timeout: timeout, backoff: time.Second}
+}
+
+func (p *CachingProvider) Name() string { return "caching:" + p.Inner.Name() }
+
+func (p *CachingProvider) GetMNList(ctx context.Context) (map[string]MNInfo, error) {
+	p.mu.RLock()
+	tooSoon := time.Now().Before(p.nextRetry)
+	cached := p.cached
+	lastErr := p.lastErr
+	p.mu.RUnlock()
+
+	if tooSoon {
+		if cached != nil {
+			return cached, nil
+		}
+		return nil, lastErr
+	}
+
+	fetchCtx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	list, err := p.Inner.GetMNList(fetchCtx)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err != nil {
+		p.lastErr = err
+		if p.backoff < time.Minute {
+			p.backoff *= 2
+		}
+		p.nextRetry = time.Now().Add(p.backoff)
+		if p.cached != nil {
+			// serve the last-known-good list rather than poisoning callers
+			return p.cached, nil
+		}
+		return nil, err
+	}
+
+	p.lastErr = nil
+	p.backoff = time.Second
+	p.nextRetry = time.Time{}
+	p.cached = list
+	p.lastFetch = time.Now()
+	return list, nil
+}
+
+// Status reports which provider produced the current list, when, and any
+// last error, for the /api/mnlist/source diagnostic.
+func (p *CachingProvider) Status() mnlistSourceStatus {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	status := mnlistSourceStatus{
+		Source:    p.Inner.Name(),
+		FetchedAt: p.lastFetch,
+	}
+	if p.lastErr != nil {
+		status.LastError = p.lastErr.Error()
+	}
+	return status
+}
+
+type mnlistSourceStatus struct {
+	Source    string    `json:"source"`
+	FetchedAt time.Time `json:"fetchedAt"`
+	LastError string    `json:"lastError,omitempty"`
+}
+
+// handleMNListSource reports which provider produced the current mnlist.
+func (s *server) handleMNListSource() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(s.mnListProvider.Status())
+	}
+}
+
+// newMNListProvider builds the configured provider chain from the
+// environment. MNLIST_PROVIDER selects the backend: "http" (default),
+// "dashd-rpc", "platform", or "file".
+//
+// "platform" is not implemented yet (see PlatformMNListProvider) - it
+// exists only so config can select it ahead of that work landing.
+// Selecting it will surface as a permanent error on
+// /api/mnlist/source rather than a working mnlist.
+func newMNListProvider() MNListProvider {
+	var inner MNListProvider
+
+	switch os.Getenv("MNLIST_PROVIDER") {
+	case "dashd-rpc":
+		inner = &DashdRPCMNListProvider{
+			URL:      os.Getenv("DASHD_RPC_URL"),
+			User:     os.Getenv("DASHD_RPC_USER"),
+			Password: os.Getenv("DASHD_RPC_PASSWORD"),
+		}
+	case "platform":
+		inner = &PlatformMNListProvider{Endpoint: os.Getenv("PLATFORM_GRPC_ENDPOINT")}
+	case "file":
+		inner = &StaticFileMNListProvider{Path: os.Getenv("MNLIST_FILE_PATH")}
+	default:
+		inner = &HTTPMNListProvider{URL: os.Getenv("MNLIST_URL")}
+	}
+
+	return NewCachingProvider(inner, 5*time.Second)
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dashhive/dashmsg"
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// ReceiptClaims is the canonicalized, signed form of an accepted vote.
+// It is carried as the claims of a compact JWS so that a masternode
+// operator (or anyone else) can verify, offline, that the server really
+// did record their vote exactly as submitted.
+type ReceiptClaims struct {
+	Address   string `json:"address"`
+	Message   string `json:"message"`
+	Signature string `json:"signature"`
+	SigScheme string `json:"sigScheme"`
+	CreatedAt string `json:"created_at"`
+	Sequence  int64  `json:"sequence"`
+	jwt.StandardClaims
+}
+
+// signReceipt builds and signs a receipt for a just-inserted vote.
+func signReceipt(v *Vote, sequence int64) (string, error) {
+	claims := ReceiptClaims{
+		Address:   v.Address,
+		Message:   v.Message,
+		Signature: v.Signature,
+		SigScheme: v.SigScheme,
+		CreatedAt: v.CreatedAt.Format(timeFormatRFC3339),
+		Sequence:  sequence,
+		StandardClaims: jwt.StandardClaims{
+			Issuer:   serverKeyID,
+			IssuedAt: v.CreatedAt.Unix(),
+		},
+	}
+
+	token := jwt.NewWithClaims(serverSigningMethod, claims)
+	token.Header["kid"] = serverKeyID
+
+	return token.SignedString(serverSigningKey)
+}
+
+// timeFormatRFC3339 pins the layout used inside a receipt, independent of
+// however Vote.CreatedAt happens to marshal to JSON elsewhere.
+const timeFormatRFC3339 = "2006-01-02T15:04:05.999999999Z07:00"
+
+// parseReceipt verifies the server's own signature on a receipt and
+// returns its claims. It does not re-check the enclosed Dash signature;
+// callers that need full verification should also call
+// dashmsg.MagicVerify on the returned claims (see handleReceiptVerify).
+func parseReceipt(compact string) (*ReceiptClaims, error) {
+	claims := &ReceiptClaims{}
+	token, err := jwt.ParseWithClaims(compact, claims, keystore.keyFunc)
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, fmt.Errorf("receipt signature is not valid")
+	}
+	if kid, _ := token.Header["kid"].(string); kid != serverKeyID {
+		return nil, fmt.Errorf("receipt was not signed by this server")
+	}
+	return claims, nil
+}
+
+// receiptVerifyRequest is the body accepted by /api/receipt/verify.
+type receiptVerifyRequest struct {
+	Receipt string `json:"receipt"`
+}
+
+// receiptVerifyResponse reports the outcome of verifying both signatures
+// enclosed in a receipt.
+type receiptVerifyResponse struct {
+	Status         int    `json:"status"`
+	ServerSigValid bool   `json:"serverSignatureValid"`
+	VoteSigValid   bool   `json:"voteSignatureValid"`
+	Address        string `json:"address,omitempty"`
+	Sequence       int64  `json:"sequence,omitempty"`
+	Message        string `json:"message,omitempty"`
+}
+
+// handleReceiptVerify re-checks both the server's signature over the
+// receipt and the voter's original signature it encloses - Dash-message
+// ECDSA for a legacy voting address, or BLS against the pubKeyOperator
+// for a DIP-0024 EvoNode vote, per claims.SigScheme (see handleVote).
+func (s *server) handleReceiptVerify() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body receiptVerifyRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(http.StatusBadRequest, w, r)
+			return
+		}
+
+		claims, err := parseReceipt(body.Receipt)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			_ = enc.Encode(receiptVerifyResponse{
+				Status:         http.StatusOK,
+				ServerSigValid: false,
+				Message:        err.Error(),
+			})
+			return
+		}
+
+		var voteSigErr error
+		switch claims.SigScheme {
+		case "bls":
+			s.candidatesMux.RLock()
+			mn, ok := s.mnList[claims.Address]
+			s.candidatesMux.RUnlock()
+			if !ok {
+				voteSigErr = fmt.Errorf("unknown operator: %s", claims.Address)
+			} else {
+				voteSigErr = verifyBLSVote(mn.PubKeyOperator, []byte(claims.Message), claims.Signature)
+			}
+		default:
+			voteSigErr = dashmsg.MagicVerify(claims.Address, []byte(claims.Message), claims.Signature)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(receiptVerifyResponse{
+			Status:         http.StatusOK,
+			ServerSigValid: true,
+			VoteSigValid:   voteSigErr == nil,
+			Address:        claims.Address,
+			Sequence:       claims.Sequence,
+		})
+	}
+}
+
+// getVoteBySequence looks up a single vote by its append-only vote-log
+// sequence number, scanning the full log the same way getCurrentVotesAsOf
+// does. It returns (nil, nil) if no vote with that sequence exists.
+func getVoteBySequence(d db, sequence int64) (*Vote, error) {
+	all, err := getAllVotes(d)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range all {
+		if v.Sequence == sequence {
+			return &v, nil
+		}
+	}
+	return nil, nil
+}
+
+// handleReceiptBySequence fetches a previously-issued receipt by its
+// append-only vote-log sequence number, re-signing it fresh each time so
+// that the response is always independently verifiable.
+func (s *server) handleReceiptBySequence() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		seqStr := parts[len(parts)-1]
+
+		sequence, err := strconv.ParseInt(seqStr, 10, 64)
+		if err != nil {
+			writeErrorMessage("E_INVALID_SEQUENCE", http.StatusBadRequest, w, r)
+			return
+		}
+
+		v, err := getVoteBySequence(s.db, sequence)
+		if err != nil {
+			writeErrorMessage("E_DATABASE_GET_VOTE", http.StatusInternalServerError, w, r)
+			return
+		}
+		if v == nil {
+			writeError(http.StatusNotFound, w, r)
+			return
+		}
+
+		receipt, err := signReceipt(v, sequence)
+		if err != nil {
+			writeErrorMessage("E_RECEIPT_SIGN", http.StatusInternalServerError, w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(JSONResult{
+			Status:  http.StatusOK,
+			Message: receipt,
+		})
+	}
+}
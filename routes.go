@@ -1,10 +1,11 @@
 package main
 
 import (
+	"context"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log"
-	"net"
 	"net/http"
 	"os"
 	"strings"
@@ -15,11 +16,6 @@ import (
 	jwt "github.com/dgrijalva/jwt-go"
 )
 
-// JWTSecretKey is used to verify the JWT was signed w/the same, used for
-// authorization.
-// See also: https://jwt.io/#debugger
-var JWTSecretKey []byte
-
 // DashNetwork is used for validating the address network byte
 var DashNetwork string
 
@@ -39,6 +35,23 @@ func (s *server) routes() {
 	s.router.HandleFunc("/api/candidates", s.handleCandidates())
 	s.router.HandleFunc("/api/votingaddresses", s.handleVotingAddresses())
 	s.router.HandleFunc("/api/mnlist", s.handleMNList())
+	s.router.HandleFunc("/api/mnlist/source", s.handleMNListSource())
+
+	// publish our own public key so operators can mint audit tokens with
+	// `signjwt` (see cmd/signjwt) without sharing a secret
+	s.router.HandleFunc("/.well-known/jwks.json", s.handleJWKS())
+
+	// vote receipts: proof a vote was recorded, independent of trusting us
+	s.router.HandleFunc("/api/receipt/verify", s.handleReceiptVerify())
+	s.router.HandleFunc("/api/receipt/{seq}", s.handleReceiptBySequence())
+
+	// anti-spam: a voter must mine a PoW stamp before /api/vote will accept
+	// their (expensive-to-verify) ECDSA signature
+	s.router.HandleFunc("/api/new-hashcash", s.handleNewHashcash())
+
+	// weighted tallying: public once voting has closed, JWT-gated before
+	s.router.HandleFunc("/api/results", s.isAuthorizedOrTimely(s.handleResults()))
+	s.router.HandleFunc("/api/results/stream", s.isAuthorizedOrTimely(s.handleResultsStream()))
 
 	// audit routes
 	// the public can view all votes once the voting has concluded
@@ -70,25 +83,35 @@ func (s *server) isAuthorizedOrTimely(f http.HandlerFunc) http.HandlerFunc {
 		// strip the "Bearer " from the beginning
 		actualTokenStr := strings.TrimPrefix(bearerToken[0], "Bearer ")
 
-		// Parse and validate token from request header
-		token, err := jwt.Parse(actualTokenStr, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return "invalid signing method", nil
-			}
-			return JWTSecretKey, nil
-		})
-		if err != nil {
+		// Parse and validate token from request header. The verification key
+		// is resolved per-token by its `kid` header, so any issuer whose
+		// JWKS we trust (ourselves, or an operator's `signjwt` key) can
+		// grant audit access without us sharing a secret.
+		token, err := jwt.Parse(actualTokenStr, keystore.keyFunc)
+		if err != nil || !token.Valid {
 			writeError(http.StatusUnauthorized, w, r)
 			return
 		}
 
-		// JWT is valid, pass the request thru to protected route
-		if token.Valid {
-			f(w, r)
+		// A trusted issuer's key may also sign tokens for unrelated
+		// services, so a verified signature alone isn't enough - require
+		// the audience to be ours too. (TODO: pin `iss` per trusted kid,
+		// once the keystore tracks which issuer vouched for each key.)
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok || claims["aud"] != audienceVoteCollector {
+			writeError(http.StatusUnauthorized, w, r)
+			return
 		}
+
+		f(w, r)
 	}
 }
 
+// audienceVoteCollector is the `aud` every token accepted by
+// isAuthorizedOrTimely must carry - cmd/signjwt defaults its own -aud
+// flag to the same value.
+const audienceVoteCollector = "vote-collector"
+
 func (s *server) updateLists() error {
 	s.candidatesUpdateMux.Lock()
 	defer s.candidatesUpdateMux.Unlock()
@@ -119,7 +142,11 @@ func (s *server) updateLists() error {
 		if s.candidatesUpdatedAt.Sub(s.votingEnd) > 0 {
 			fmt.Fprintf(os.Stderr, "BUG: Updating mnlist AFTER vote has closed (TODO fix)")
 		}
-		mnList = s.getMNList()
+		fresh, err := s.mnListProvider.GetMNList(context.Background())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+		mnList = fresh
 	}
 	s.candidatesMux.RUnlock()
 
@@ -139,52 +166,6 @@ func (s *server) updateLists() error {
 	return nil
 }
 
-func (s *server) getMNList() map[string]MNInfo {
-	c := &http.Client{
-		Transport: &http.Transport{
-			Dial: (&net.Dialer{
-				Timeout:   30 * time.Second,
-				KeepAlive: 30 * time.Second,
-			}).Dial,
-			TLSHandshakeTimeout:   10 * time.Second,
-			ResponseHeaderTimeout: 10 * time.Second,
-			ExpectContinueTimeout: 1 * time.Second,
-		},
-	}
-
-	ch := make(chan struct{})
-	//timer :=
-	time.AfterFunc(5*time.Second, func() {
-		close(ch)
-	})
-	// timer.Reset
-
-	req, err := http.NewRequest("GET", s.mnlistURL, nil)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		return nil
-	}
-	req.Cancel = ch
-
-	log.Println("Sending request...")
-	resp, err := c.Do(req)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		return nil
-	}
-	defer resp.Body.Close()
-
-	mninfo := map[string]MNInfo{}
-
-	dec := json.NewDecoder(resp.Body)
-	if err := dec.Decode(&mninfo); nil != err {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		return nil
-	}
-
-	return mninfo
-}
-
 // handleCandidates handles the candidates route
 func (s *server) handleCandidates() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -276,8 +257,45 @@ func (s *server) handleVote() http.HandlerFunc {
 			writeErrorMessage("INVALID_NETWORK", http.StatusBadRequest, w, r)
 			return
 		}
-		if err := dashmsg.MagicVerify(v.Address, []byte(v.Message), v.Signature); nil != err {
-			writeErrorMessage("INVALID_SIGNATURE: "+err.Error(), http.StatusBadRequest, w, r)
+
+		// Require a mined PoW stamp before doing the expensive ECDSA
+		// verification below, so flooding this endpoint with garbage
+		// signatures costs the attacker real CPU time too.
+		stamp := r.Header.Get("Hashcash")
+		if stamp == "" {
+			writeErrorMessage("E_HASHCASH_REQUIRED", http.StatusPaymentRequired, w, r)
+			return
+		}
+		if err := verifyHashcash(stamp, v.Address); err != nil {
+			writeErrorMessage("E_HASHCASH_INVALID: "+err.Error(), http.StatusPaymentRequired, w, r)
+			return
+		}
+
+		// HPMN/EvoNode operators vote with their DIP-0024 BLS operator key
+		// instead of a legacy Dash-message-signed voting address; everyone
+		// else keeps using the existing ECDSA path.
+		switch v.SigScheme {
+		case "", "ecdsa":
+			v.SigScheme = "ecdsa"
+			if err := dashmsg.MagicVerify(v.Address, []byte(v.Message), v.Signature); nil != err {
+				writeErrorMessage("INVALID_SIGNATURE: "+err.Error(), http.StatusBadRequest, w, r)
+				return
+			}
+		case "bls":
+			s.candidatesMux.RLock()
+			mn, ok := s.mnList[v.Address]
+			s.candidatesMux.RUnlock()
+			if !ok {
+				writeErrorMessage("E_UNKNOWN_OPERATOR", http.StatusBadRequest, w, r)
+				return
+			}
+			if err := verifyBLSVote(mn.PubKeyOperator, []byte(v.Message), v.Signature); nil != err {
+				writeErrorMessage("INVALID_SIGNATURE: "+err.Error(), http.StatusBadRequest, w, r)
+				return
+			}
+		default:
+			writeErrorMessage("E_UNKNOWN_SIG_SCHEME", http.StatusBadRequest, w, r)
+			return
 		}
 
 		// Insert vote
@@ -287,13 +305,36 @@ func (s *server) handleVote() http.HandlerFunc {
 			return
 		}
 
+		// Push an updated tally to any /api/results/stream subscribers.
+		go func() {
+			votes, err := getCurrentVotesOnly(s.db)
+			if err != nil {
+				log.Printf("Failed to refresh tally: %v\n", err)
+				return
+			}
+			s.candidatesMux.RLock()
+			mnList := s.mnList
+			s.candidatesMux.RUnlock()
+			tally.Publish(mnList, votes)
+		}()
+
+		// Sign a receipt so the voter has cryptographic, non-repudiable
+		// proof that this exact vote was recorded - not just the
+		// human-readable message below.
+		receipt, err := signReceipt(&v, v.Sequence)
+		if err != nil {
+			writeErrorMessage("E_RECEIPT_SIGN", http.StatusInternalServerError, w, r)
+			return
+		}
+
 		// Return response
 		w.Header().Set("Content-Type", "application/json")
 		enc := json.NewEncoder(w)
 		enc.SetIndent("", "  ")
-		_ = enc.Encode(JSONResult{
+		_ = enc.Encode(VoteResult{
 			Status:  http.StatusCreated,
 			Message: "Vote Recorded",
+			Receipt: receipt,
 		})
 	}
 }
@@ -374,6 +415,14 @@ type JSONResult struct {
 	Message string `json:"message"`
 }
 
+// VoteResult is the response to a successful /api/vote, carrying a signed
+// receipt (see receipt.go) alongside the usual human-readable message.
+type VoteResult struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+	Receipt string `json:"receipt"`
+}
+
 // writeErrorMessage returns a JSON error with a helpful message.
 func writeErrorMessage(msg string, errorCode int, w http.ResponseWriter, r *http.Request) {
 	result := JSONErrorMessage{
@@ -410,14 +459,16 @@ func (s *server) handleIndex() http.HandlerFunc {
 	}
 }
 
-func init() {
-	JWTSecretKey = []byte(os.Getenv("JWT_SECRET_KEY"))
-}
-
 // helper methods
 
-// isValidAddress checks if a given string is a valid Dash address
+// isValidAddress checks if a given string is a valid Dash address, or (for
+// DIP-0024 HPMN/EvoNode BLS votes, which aren't signed by a legacy voting
+// address) a ProRegTx hash identifying the operator.
 func isValidAddress(addr string, dashNetwork string) bool {
+	if isProRegTxHash(addr) {
+		return true
+	}
+
 	decoded, version, err := base58.CheckDecode(addr)
 	if err != nil {
 		return false
@@ -438,3 +489,13 @@ func isValidAddress(addr string, dashNetwork string) bool {
 
 	return len(decoded) == 20
 }
+
+// isProRegTxHash reports whether addr looks like a ProRegTx hash
+// (a 32-byte txid, hex-encoded) rather than a base58 voting address.
+func isProRegTxHash(addr string) bool {
+	if len(addr) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(addr)
+	return err == nil
+}
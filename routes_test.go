@@ -0,0 +1,47 @@
+package main
+
+import "testing"
+
+func TestIsProRegTxHash(t *testing.T) {
+	cases := []struct {
+		name string
+		addr string
+		want bool
+	}{
+		{"64 hex chars", "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f", true},
+		{"too short", "0102030405", false},
+		{"64 chars but not hex", "zz0102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f", false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := isProRegTxHash(c.addr); got != c.want {
+				t.Errorf("isProRegTxHash(%q) = %v, want %v", c.addr, got, c.want)
+			}
+		})
+	}
+}
+
+func TestIsValidAddress(t *testing.T) {
+	cases := []struct {
+		name        string
+		addr        string
+		dashNetwork string
+		want        bool
+	}{
+		{"mainnet p2pkh", "XanAvE5GMB8CsPH78B9moJq9viEVKvCS4f", "mainnet", true},
+		{"mainnet p2sh", "7SVyqiBykMKdoNuuf1AehnVxASmtdfqsFF", "mainnet", true},
+		{"testnet p2pkh", "yLQmwB9hninHD8Ceh2UAqLFWCzirppNLik", "testnet", true},
+		{"testnet p2sh", "8eWno35qstiGFgLAjGAcAAKK3xYijokhhY", "testnet", true},
+		{"wrong network for address", "XanAvE5GMB8CsPH78B9moJq9viEVKvCS4f", "testnet", false},
+		{"unsupported network", "XanAvE5GMB8CsPH78B9moJq9viEVKvCS4f", "regtest", false},
+		{"garbage", "not-a-real-address", "mainnet", false},
+		{"ProRegTx hash counts as valid regardless of network", "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f", "mainnet", true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := isValidAddress(c.addr, c.dashNetwork); got != c.want {
+				t.Errorf("isValidAddress(%q, %q) = %v, want %v", c.addr, c.dashNetwork, got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// CandidateResult is one candidate's weighted standing in the tally.
+// A single voting key that controls N collaterals counts as N votes of
+// weight, per the TODO in updateLists. VoteCount counts every vote row
+// Compute was given; VotingAddressCount counts only the distinct voting
+// addresses behind them - today's callers already pass one vote per
+// address, so the two happen to match, but they stop matching the moment
+// a caller (e.g. a future raw append-only-log view) doesn't pre-dedupe.
+type CandidateResult struct {
+	Candidate          string `json:"candidate"`
+	VoteCount          int    `json:"voteCount"`
+	CollateralWeight   int    `json:"collateralWeight"`
+	VotingAddressCount int    `json:"votingAddressCount"`
+}
+
+// Tally computes, and optionally streams, weighted per-candidate results.
+type Tally struct {
+	subsMux sync.RWMutex
+	subs    map[chan []CandidateResult]struct{}
+}
+
+var tally = &Tally{subs: map[chan []CandidateResult]struct{}{}}
+
+// Compute builds per-candidate results from the current mnList and the
+// most-recent-vote-per-voting-key snapshot returned by
+// getCurrentVotesOnly. A voting address that controls multiple
+// collaterals (mnList entries sharing VotingAddress) has its vote counted
+// once per collateral it controls.
+func (t *Tally) Compute(mnList map[string]MNInfo, votes []Vote) []CandidateResult {
+	collateralsByVotingAddress := map[string]int{}
+	for _, mn := range mnList {
+		collateralsByVotingAddress[mn.VotingAddress]++
+	}
+
+	byCandidate := map[string]*CandidateResult{}
+	seenAddresses := map[string]map[string]struct{}{}
+	for _, v := range votes {
+		// a voting address that doesn't currently control any collateral
+		// contributes zero weight, but is still counted toward VoteCount
+		weight := collateralsByVotingAddress[v.Address]
+
+		r, ok := byCandidate[v.Message]
+		if !ok {
+			r = &CandidateResult{Candidate: v.Message}
+			byCandidate[v.Message] = r
+			seenAddresses[v.Message] = map[string]struct{}{}
+		}
+		r.VoteCount++
+		r.CollateralWeight += weight
+		if _, dup := seenAddresses[v.Message][v.Address]; !dup {
+			seenAddresses[v.Message][v.Address] = struct{}{}
+			r.VotingAddressCount++
+		}
+	}
+
+	results := make([]CandidateResult, 0, len(byCandidate))
+	for _, r := range byCandidate {
+		results = append(results, *r)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].CollateralWeight > results[j].CollateralWeight
+	})
+	return results
+}
+
+// Publish computes a fresh tally and pushes it to every subscriber of
+// /api/results/stream.
+func (t *Tally) Publish(mnList map[string]MNInfo, votes []Vote) []CandidateResult {
+	results := t.Compute(mnList, votes)
+
+	t.subsMux.RLock()
+	defer t.subsMux.RUnlock()
+	for ch := range t.subs {
+		select {
+		case ch <- results:
+		default:
+			// a slow subscriber misses an update rather than blocking the tally
+		}
+	}
+	return results
+}
+
+// subscribe registers a channel to receive each published tally until
+// unsubscribe is called.
+func (t *Tally) subscribe() chan []CandidateResult {
+	ch := make(chan []CandidateResult, 1)
+	t.subsMux.Lock()
+	t.subs[ch] = struct{}{}
+	t.subsMux.Unlock()
+	return ch
+}
+
+func (t *Tally) unsubscribe(ch chan []CandidateResult) {
+	t.subsMux.Lock()
+	delete(t.subs, ch)
+	t.subsMux.Unlock()
+	close(ch)
+}
+
+// getCurrentVotesAsOf reconstructs the "most current vote per voting
+// address" snapshot as it stood at cutoff, from the append-only vote log
+// (getAllVotes), so /api/results?asOf= can reproduce a historical tally
+// rather than only ever the live one.
+func getCurrentVotesAsOf(d db, cutoff time.Time) ([]Vote, error) {
+	all, err := getAllVotes(d)
+	if err != nil {
+		return nil, err
+	}
+
+	latest := map[string]Vote{}
+	for _, v := range all {
+		if v.CreatedAt.After(cutoff) {
+			continue
+		}
+		prev, ok := latest[v.Address]
+		if !ok || v.CreatedAt.After(prev.CreatedAt) || (v.CreatedAt.Equal(prev.CreatedAt) && v.Sequence > prev.Sequence) {
+			latest[v.Address] = v
+		}
+	}
+
+	votes := make([]Vote, 0, len(latest))
+	for _, v := range latest {
+		votes = append(votes, v)
+	}
+	return votes, nil
+}
+
+// handleResults serves the current (or, with ?asOf=, a historical) tally,
+// gated by isAuthorizedOrTimely until voting closes.
+func (s *server) handleResults() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var votes []Vote
+		var err error
+
+		if asOf := r.URL.Query().Get("asOf"); asOf != "" {
+			cutoff, parseErr := time.Parse(time.RFC3339, asOf)
+			if parseErr != nil {
+				writeErrorMessage("E_INVALID_ASOF", http.StatusBadRequest, w, r)
+				return
+			}
+			votes, err = getCurrentVotesAsOf(s.db, cutoff)
+		} else {
+			votes, err = getCurrentVotesOnly(s.db)
+		}
+		if err != nil {
+			writeErrorMessage("E_DATABASE_GET_VALID", http.StatusInternalServerError, w, r)
+			return
+		}
+
+		s.candidatesMux.RLock()
+		mnList := s.mnList
+		s.candidatesMux.RUnlock()
+
+		results := tally.Compute(mnList, votes)
+
+		if r.URL.Query().Get("format") == "csv" {
+			writeResultsCSV(w, results)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(results)
+	}
+}
+
+// writeResultsCSV writes results as candidate,voteCount,collateralWeight,votingAddressCount.
+func writeResultsCSV(w http.ResponseWriter, results []CandidateResult) {
+	w.Header().Set("Content-Type", "text/csv")
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"candidate", "voteCount", "collateralWeight", "votingAddressCount"})
+	for _, r := range results {
+		_ = cw.Write([]string{
+			r.Candidate,
+			strconv.Itoa(r.VoteCount),
+			strconv.Itoa(r.CollateralWeight),
+			strconv.Itoa(r.VotingAddressCount),
+		})
+	}
+	cw.Flush()
+}
+
+// handleResultsStream serves tally updates as Server-Sent Events, so a
+// dashboard doesn't have to poll /api/results. Each event carries the
+// complete tally as published after an accepted vote, not a difference
+// from the previous event.
+func (s *server) handleResultsStream() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			writeError(http.StatusInternalServerError, w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+
+		ch := tally.subscribe()
+		defer tally.unsubscribe(ch)
+
+		for {
+			select {
+			case results, ok := <-ch:
+				if !ok {
+					return
+				}
+				payload, err := json.Marshal(results)
+				if err != nil {
+					continue
+				}
+				fmt.Fprintf(w, "data: %s\n\n", payload)
+				flusher.Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestTallyComputeWeightsByCollateral(t *testing.T) {
+	mnList := map[string]MNInfo{
+		"mn1": {VotingAddress: "addrA"},
+		"mn2": {VotingAddress: "addrA"}, // addrA controls two collaterals
+		"mn3": {VotingAddress: "addrB"},
+	}
+	votes := []Vote{
+		{Address: "addrA", Message: "alice"},
+		{Address: "addrB", Message: "alice"},
+		{Address: "addrC", Message: "bob"}, // no longer controls any collateral
+	}
+
+	results := tally.Compute(mnList, votes)
+
+	byCandidate := map[string]CandidateResult{}
+	for _, r := range results {
+		byCandidate[r.Candidate] = r
+	}
+
+	alice := byCandidate["alice"]
+	if alice.CollateralWeight != 3 {
+		t.Errorf("alice.CollateralWeight = %d, want 3", alice.CollateralWeight)
+	}
+	if alice.VoteCount != 2 {
+		t.Errorf("alice.VoteCount = %d, want 2", alice.VoteCount)
+	}
+
+	bob := byCandidate["bob"]
+	if bob.CollateralWeight != 0 {
+		t.Errorf("bob.CollateralWeight = %d, want 0", bob.CollateralWeight)
+	}
+	if bob.VoteCount != 1 {
+		t.Errorf("bob.VoteCount = %d, want 1", bob.VoteCount)
+	}
+}
+
+func TestTallyComputeSortsByWeightDescending(t *testing.T) {
+	mnList := map[string]MNInfo{
+		"mn1": {VotingAddress: "addrA"},
+		"mn2": {VotingAddress: "addrB"},
+		"mn3": {VotingAddress: "addrB"},
+	}
+	votes := []Vote{
+		{Address: "addrA", Message: "alice"},
+		{Address: "addrB", Message: "bob"},
+	}
+
+	results := tally.Compute(mnList, votes)
+
+	if len(results) != 2 || results[0].Candidate != "bob" || results[1].Candidate != "alice" {
+		t.Fatalf("got %+v, want bob (weight 2) ahead of alice (weight 1)", results)
+	}
+}